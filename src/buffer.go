@@ -0,0 +1,339 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// pieceSource はピースの実体がoriginal(読み込み時点の内容)か
+// add(挿入した文字列を追記していくバッファ)のどちらを指すかを表す
+type pieceSource int
+
+const (
+	sourceOriginal pieceSource = iota
+	sourceAdd
+)
+
+// piece はoriginal/addバッファの一部分への参照 {source, offset, length}
+type piece struct {
+	source pieceSource
+	offset int
+	length int
+}
+
+// Edit はUndo/Redoの1単位を表す差分。pos はバッファ全体でのバイトオフセット
+type Edit struct {
+	pos	  int
+	inserted string
+	deleted  string
+}
+
+// coalesceWindow 以内に続く単文字挿入は1つのUndoグループにまとめる
+const coalesceWindow = 500 * time.Millisecond
+
+// Buffer はピーステーブルで管理する編集バッファ
+// original/add の2つの実体バッファと、それらを指すピース列でテキスト全体を表現する
+type Buffer struct {
+	original string
+	add	  string
+	pieces   []piece
+
+	// cachedText/lineStartsは常に最新の内容と同期しており、
+	// insertAt/deleteAtが変更範囲だけを差分更新する (全文の再走査はしない)
+	lineStarts []int
+	cachedText string
+
+	undoStack []Edit
+	redoStack []Edit
+	lastEdit  time.Time
+}
+
+// newBuffer は読み込んだ行データ (各行末に"\n"を含む) からピーステーブルを構築する
+func newBuffer(lines []string) *Buffer {
+	b := &Buffer{original: strings.Join(lines, "")}
+	if len(b.original) > 0 {
+		b.pieces = []piece{{sourceOriginal, 0, len(b.original)}}
+	}
+	b.cachedText = b.original
+	b.lineStarts = linesStartsOf(b.cachedText)
+	return b
+}
+
+// source はピースが指す実体の部分文字列を返す
+func (b *Buffer) source(p piece) string {
+	if p.source == sourceOriginal {
+		return b.original[p.offset : p.offset+p.length]
+	}
+	return b.add[p.offset : p.offset+p.length]
+}
+
+// Text はピース列を連結して現在の全文を返す
+func (b *Buffer) Text() string {
+	var sb strings.Builder
+	for _, p := range b.pieces {
+		sb.WriteString(b.source(p))
+	}
+	return sb.String()
+}
+
+// linesStartsOf はtextを1度だけ走査して各行の先頭オフセットを求める
+// (newBufferでの初回構築にのみ使う。以降はinsertLineStarts/deleteLineStartsが
+// 変更範囲だけを差分更新する)
+func linesStartsOf(text string) []int {
+	if len(text) == 0 {
+		return nil
+	}
+	starts := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' && i+1 < len(text) {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+// insertLineStarts はpos位置にsを挿入した後のlineStartsを、
+// 変更範囲だけ更新する (呼び出し前にcachedTextへの反映が済んでいること)
+func (b *Buffer) insertLineStarts(pos int, s string) {
+	for i, start := range b.lineStarts {
+		if start > pos {
+			b.lineStarts[i] = start + len(s)
+		}
+	}
+	if len(b.lineStarts) == 0 && len(b.cachedText) > 0 {
+		b.lineStarts = append(b.lineStarts, 0)
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\n' {
+			continue
+		}
+		start := pos + i + 1
+		if start >= len(b.cachedText) {
+			continue
+		}
+		b.insertLineStart(start)
+	}
+
+	// posの直前が既存の改行で、それまでバッファ末尾だった(=行頭を持たなかった)場合、
+	// 今回の挿入でその後ろに内容ができたので新たに行頭になる
+	if pos > 0 && b.cachedText[pos-1] == '\n' {
+		b.insertLineStart(pos)
+	}
+}
+
+// insertLineStart はlineStartsにstartを(重複なく)挿入位置を保ったまま追加する
+func (b *Buffer) insertLineStart(start int) {
+	j := sort.SearchInts(b.lineStarts, start)
+	if j < len(b.lineStarts) && b.lineStarts[j] == start {
+		return
+	}
+	b.lineStarts = append(b.lineStarts, 0)
+	copy(b.lineStarts[j+1:], b.lineStarts[j:])
+	b.lineStarts[j] = start
+}
+
+// deleteLineStarts はposからlengthバイトを削除した後のlineStartsを、
+// 変更範囲だけ更新する (呼び出し前にcachedTextへの反映が済んでいること)
+func (b *Buffer) deleteLineStarts(pos, length int) {
+	end := pos + length
+	kept := make([]int, 0, len(b.lineStarts))
+	for _, start := range b.lineStarts {
+		switch {
+		case start <= pos:
+			kept = append(kept, start)
+		case start <= end:
+			// 削除範囲内(またはその直後の削除された改行が作っていた)行頭は消える
+		default:
+			kept = append(kept, start-length)
+		}
+	}
+	b.lineStarts = kept
+
+	// 削除の結果、末尾の改行の直後(=バッファ末尾)を指すだけの行頭が残っていたら
+	// それはもう行を持たないので取り除く
+	for n := len(b.lineStarts); n > 0 && b.lineStarts[n-1] >= len(b.cachedText); n = len(b.lineStarts) {
+		b.lineStarts = b.lineStarts[:n-1]
+	}
+}
+
+// Snapshot は現在の全文を返す
+func (b *Buffer) Snapshot() string {
+	return b.cachedText
+}
+
+// Lines は現在の内容を行ごとに分割して返す (各行は末尾に"\n"を含む)
+func (b *Buffer) Lines() []string {
+	lines := make([]string, 0, len(b.lineStarts))
+	for i, start := range b.lineStarts {
+		end := len(b.cachedText)
+		if i+1 < len(b.lineStarts) {
+			end = b.lineStarts[i+1]
+		}
+		lines = append(lines, b.cachedText[start:end])
+	}
+	return lines
+}
+
+// pieceAt はバイトオフセットposを含むピースのインデックスと、
+// そのピース先頭からのオフセットを返す
+func (b *Buffer) pieceAt(pos int) (int, int) {
+	acc := 0
+	for i, p := range b.pieces {
+		if pos <= acc+p.length {
+			return i, pos - acc
+		}
+		acc += p.length
+	}
+	return len(b.pieces), 0
+}
+
+// insertAt はUndo記録をせずにバイトオフセットposへsを挿入する
+func (b *Buffer) insertAt(pos int, s string) {
+	if s == "" {
+		return
+	}
+	addOffset := len(b.add)
+	b.add += s
+	np := piece{sourceAdd, addOffset, len(s)}
+
+	idx, within := b.pieceAt(pos)
+	switch {
+	case idx == len(b.pieces):
+		b.pieces = append(b.pieces, np)
+	case within == 0:
+		b.pieces = append(b.pieces[:idx:idx], append([]piece{np}, b.pieces[idx:]...)...)
+	default:
+		p := b.pieces[idx]
+		left := piece{p.source, p.offset, within}
+		right := piece{p.source, p.offset + within, p.length - within}
+		tail := append([]piece{np, right}, b.pieces[idx+1:]...)
+		b.pieces = append(b.pieces[:idx:idx], left)
+		b.pieces = append(b.pieces, tail...)
+	}
+
+	b.cachedText = b.cachedText[:pos] + s + b.cachedText[pos:]
+	b.insertLineStarts(pos, s)
+}
+
+// deleteAt はUndo記録をせずにバイトオフセットposからlengthバイトを削除し、
+// 削除した文字列を返す
+func (b *Buffer) deleteAt(pos, length int) string {
+	if length <= 0 {
+		return ""
+	}
+	var deleted strings.Builder
+	newPieces := make([]piece, 0, len(b.pieces))
+	acc := 0
+
+	for _, p := range b.pieces {
+		pStart, pEnd := acc, acc+p.length
+		acc = pEnd
+
+		if pEnd <= pos || pStart >= pos+length {
+			newPieces = append(newPieces, p)
+			continue
+		}
+
+		cutStart := max(0, pos-pStart)
+		cutEnd := min(p.length, pos+length-pStart)
+
+		if cutStart > 0 {
+			newPieces = append(newPieces, piece{p.source, p.offset, cutStart})
+		}
+		deleted.WriteString(b.source(piece{p.source, p.offset + cutStart, cutEnd - cutStart}))
+		if cutEnd < p.length {
+			newPieces = append(newPieces, piece{p.source, p.offset + cutEnd, p.length - cutEnd})
+		}
+	}
+
+	b.pieces = newPieces
+	b.cachedText = b.cachedText[:pos] + b.cachedText[pos+length:]
+	b.deleteLineStarts(pos, length)
+	return deleted.String()
+}
+
+// Insert はposへsを挿入し、Undoスタックに記録する (Redoスタックは破棄される)
+func (b *Buffer) Insert(pos int, s string) {
+	b.insertAt(pos, s)
+	b.pushEdit(Edit{pos: pos, inserted: s})
+}
+
+// Delete はposからlengthバイトを削除し、Undoスタックに記録する
+func (b *Buffer) Delete(pos, length int) string {
+	deleted := b.deleteAt(pos, length)
+	b.pushEdit(Edit{pos: pos, deleted: deleted})
+	return deleted
+}
+
+// pushEdit はUndoスタックへ積む。直前の記録が同じ行内の単文字挿入で、
+// coalesceWindow以内に続いていれば1つのグループにまとめる
+func (b *Buffer) pushEdit(e Edit) {
+	now := time.Now()
+	b.redoStack = nil
+
+	if n := len(b.undoStack); n > 0 && b.coalescable(e, now) {
+		last := &b.undoStack[n-1]
+		last.inserted += e.inserted
+		b.lastEdit = now
+		return
+	}
+
+	b.undoStack = append(b.undoStack, e)
+	b.lastEdit = now
+}
+
+// coalescable はeが直前のUndo記録に連結できる単文字挿入かどうかを判定する
+func (b *Buffer) coalescable(e Edit, now time.Time) bool {
+	if e.deleted != "" || utf8.RuneCountInString(e.inserted) != 1 || strings.Contains(e.inserted, "\n") {
+		return false
+	}
+	if now.Sub(b.lastEdit) > coalesceWindow {
+		return false
+	}
+	last := b.undoStack[len(b.undoStack)-1]
+	if strings.Contains(last.inserted, "\n") {
+		return false
+	}
+	return last.deleted == "" && last.pos+len(last.inserted) == e.pos
+}
+
+// Undo は直前の編集を取り消す。取り消せる編集がなければfalseを返す
+func (b *Buffer) Undo() bool {
+	n := len(b.undoStack)
+	if n == 0 {
+		return false
+	}
+	e := b.undoStack[n-1]
+	b.undoStack = b.undoStack[:n-1]
+
+	if e.inserted != "" {
+		b.deleteAt(e.pos, len(e.inserted))
+	}
+	if e.deleted != "" {
+		b.insertAt(e.pos, e.deleted)
+	}
+	b.redoStack = append(b.redoStack, e)
+	return true
+}
+
+// Redo はUndoで取り消した編集をやり直す。やり直す編集がなければfalseを返す
+func (b *Buffer) Redo() bool {
+	n := len(b.redoStack)
+	if n == 0 {
+		return false
+	}
+	e := b.redoStack[n-1]
+	b.redoStack = b.redoStack[:n-1]
+
+	if e.inserted != "" {
+		b.insertAt(e.pos, e.inserted)
+	}
+	if e.deleted != "" {
+		b.deleteAt(e.pos, len(e.deleted))
+	}
+	b.undoStack = append(b.undoStack, e)
+	return true
+}
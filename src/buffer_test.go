@@ -0,0 +1,80 @@
+package main
+
+import "testing"
+
+func linesEqual(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Insert/DeleteのたびにlineStartsが差分更新されることを確認する
+func TestBufferLinesAfterInsertDelete(t *testing.T) {
+	b := newBuffer([]string{"abc\n", "def\n", "ghi\n"})
+
+	b.Insert(4, "X\nY")
+	want := []string{"abc\n", "X\n", "Ydef\n", "ghi\n"}
+	if got := b.Lines(); !linesEqual(got, want) {
+		t.Fatalf("after insert: got %v want %v", got, want)
+	}
+
+	b.Delete(4, 3)
+	want = []string{"abc\n", "def\n", "ghi\n"}
+	if got := b.Lines(); !linesEqual(got, want) {
+		t.Fatalf("after delete: got %v want %v", got, want)
+	}
+}
+
+// Enter+Ctrl-Zで行数が縮んでもUndo/Redoが壊れないことを確認する
+func TestBufferUndoRedoLineCount(t *testing.T) {
+	b := newBuffer([]string{"abc\n"})
+
+	b.Insert(4, "\n")
+	if n := len(b.Lines()); n != 2 {
+		t.Fatalf("after insert: got %d lines, want 2", n)
+	}
+
+	if !b.Undo() {
+		t.Fatal("Undo() = false, want true")
+	}
+	if n := len(b.Lines()); n != 1 {
+		t.Fatalf("after undo: got %d lines, want 1", n)
+	}
+
+	if !b.Redo() {
+		t.Fatal("Redo() = false, want true")
+	}
+	if n := len(b.Lines()); n != 2 {
+		t.Fatalf("after redo: got %d lines, want 2", n)
+	}
+}
+
+// Enterで改行を挟んだ直後の1文字挿入は、直前のUndo記録と結合されてはならない
+func TestCoalesceStopsAtNewline(t *testing.T) {
+	b := newBuffer([]string{""})
+
+	b.Insert(0, "a\n")
+	b.Insert(2, "b")
+
+	if n := len(b.undoStack); n != 2 {
+		t.Fatalf("len(undoStack) = %d, want 2 (enter and the following keystroke must stay separate)", n)
+	}
+}
+
+// 同じ行内で続く1文字挿入は、coalesceWindow内であれば1つにまとめられる
+func TestCoalesceSameLine(t *testing.T) {
+	b := newBuffer([]string{""})
+
+	b.Insert(0, "a")
+	b.Insert(1, "b")
+
+	if n := len(b.undoStack); n != 1 {
+		t.Fatalf("len(undoStack) = %d, want 1 (same-line keystrokes should coalesce)", n)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// register はシステムクリップボードもOSC 52も使えない環境向けの
+// プロセス内クリップボード (常にwriteClipboardで更新される)
+var register string
+
+// clipboardCopyCommands はOS標準のクリップボードへの書き込みコマンド候補
+func clipboardCopyCommands() [][]string {
+	return [][]string{
+		{"pbcopy"},
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+		{"wl-copy"},
+	}
+}
+
+// clipboardPasteCommands はOS標準のクリップボードからの読み出しコマンド候補
+func clipboardPasteCommands() [][]string {
+	return [][]string{
+		{"pbpaste"},
+		{"xclip", "-selection", "clipboard", "-o"},
+		{"xsel", "--clipboard", "--output"},
+		{"wl-paste"},
+	}
+}
+
+// systemClipboardCopy はOS標準のクリップボードコマンドへのコピーを順に試す
+func systemClipboardCopy(text string) bool {
+	for _, cmd := range clipboardCopyCommands() {
+		c := exec.Command(cmd[0], cmd[1:]...)
+		stdin, err := c.StdinPipe()
+		if err != nil {
+			continue
+		}
+		if err := c.Start(); err != nil {
+			continue
+		}
+		stdin.Write([]byte(text))
+		stdin.Close()
+		if c.Wait() == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// systemClipboardPaste はOS標準のクリップボードコマンドからの読み出しを順に試す
+func systemClipboardPaste() (string, bool) {
+	for _, cmd := range clipboardPasteCommands() {
+		out, err := exec.Command(cmd[0], cmd[1:]...).Output()
+		if err == nil {
+			return string(out), true
+		}
+	}
+	return "", false
+}
+
+// writeClipboardOSC52 はOSC 52エスケープシーケンスで端末側のクリップボードへ書き込む。
+// SSH越しなどシステムクリップボードのコマンドが無い環境向けのフォールバック
+func writeClipboardOSC52(text string) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(text))
+	fmt.Fprintf(os.Stdout, "\x1b]52;c;%s\x07", encoded)
+}
+
+// writeClipboard はOSのクリップボードコマンドへコピーし、無ければOSC 52で
+// 端末のクリップボードへ書き込む。どちらの場合もプロセス内レジスタへ控えておく
+func writeClipboard(text string) {
+	register = text
+	if systemClipboardCopy(text) {
+		return
+	}
+	writeClipboardOSC52(text)
+}
+
+// readClipboard はOSのクリップボードコマンドから読み出し、使えなければ
+// プロセス内レジスタ (直前のコピー/カット内容) を返す
+func readClipboard() string {
+	if text, ok := systemClipboardPaste(); ok {
+		return text
+	}
+	return register
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/nsf/termbox-go"
+)
+
+// cmdlineMode はミニバッファが何の入力を受け付けているかを表す
+type cmdlineMode int
+
+const (
+	modeNormal cmdlineMode = iota
+	modeSearch
+	modeCommand
+)
+
+// savedView はインクリメンタルサーチ開始時のカーソル/スクロール位置の退避先
+type savedView struct {
+	cursorx		 int
+	cursory		 int
+	drawingStartRow int
+	drawingStartCol int
+}
+
+// Cmdline は画面下部のミニバッファ (検索/コマンドプロンプト) の状態を持つ
+type Cmdline struct {
+	mode		cmdlineMode
+	text		string // プレフィックス("/"か":")込みの表示用文字列
+	searchQuery string // 検索時のクエリ (プレフィックスを除いたもの)
+	saved	   savedView
+	history	 []string // :コマンドのヒストリ (古いものから並ぶリングバッファ代わりのスライス)
+	historyPos  int
+}
+
+var cmdline Cmdline
+
+const historyFileName = ".texteditor_history"
+
+func historyFilePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return historyFileName
+	}
+	return filepath.Join(home, historyFileName)
+}
+
+// loadHistory は ~/.texteditor_history から過去のコマンド履歴を読み込む
+func (c *Cmdline) loadHistory() {
+	f, err := os.Open(historyFilePath())
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		c.history = append(c.history, scanner.Text())
+	}
+	c.historyPos = len(c.history)
+}
+
+// appendHistory はコマンドをヒストリとファイルの両方に追記する
+func (c *Cmdline) appendHistory(cmd string) {
+	c.history = append(c.history, cmd)
+
+	f, err := os.OpenFile(historyFilePath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(cmd + "\n")
+}
+
+// startSearch はインクリメンタルサーチを開始し、現在位置を退避する
+func (c *Cmdline) startSearch(g *Gui) {
+	main := g.View("main")
+	c.saved = savedView{main.cursorx, main.cursory, main.drawingStartRow, main.drawingStartCol}
+	c.mode = modeSearch
+	c.searchQuery = ""
+	c.text = "/"
+}
+
+// startCommand はコマンドプロンプトを開始する
+func (c *Cmdline) startCommand(g *Gui) {
+	c.mode = modeCommand
+	c.text = ":"
+	c.historyPos = len(c.history)
+}
+
+// reset はミニバッファを閉じて通常モードへ戻す
+func (c *Cmdline) reset() {
+	c.mode = modeNormal
+	c.text = ""
+	c.searchQuery = ""
+}
+
+// cancel はEsc入力を処理する。検索中ならカーソル/スクロールを復元する
+func (c *Cmdline) cancel(g *Gui) {
+	if c.mode == modeSearch {
+		main := g.View("main")
+		main.cursorx, main.cursory = c.saved.cursorx, c.saved.cursory
+		main.drawingStartRow, main.drawingStartCol = c.saved.drawingStartRow, c.saved.drawingStartCol
+	}
+	c.reset()
+}
+
+// accept はEnter入力を処理する。検索はその場で確定し、
+// コマンドはヒストリに積んでから実行する
+func (c *Cmdline) accept(g *Gui) {
+	switch c.mode {
+	case modeSearch:
+		c.reset()
+	case modeCommand:
+		cmd := strings.TrimPrefix(c.text, ":")
+		c.reset()
+		if cmd != "" {
+			c.appendHistory(cmd)
+		}
+		executeCommand(g, cmd)
+	}
+}
+
+// insert はミニバッファに一文字挿入する
+func (c *Cmdline) insert(s string) {
+	c.text += s
+	if c.mode == modeSearch {
+		c.searchQuery += s
+	}
+}
+
+// backspace はミニバッファの末尾を一文字削除する。プレフィックスは消さない
+func (c *Cmdline) backspace() {
+	r := []rune(c.text)
+	if len(r) <= 1 {
+		return
+	}
+	c.text = string(r[:len(r)-1])
+	if c.mode == modeSearch {
+		q := []rune(c.searchQuery)
+		if len(q) > 0 {
+			c.searchQuery = string(q[:len(q)-1])
+		}
+	}
+}
+
+// historyUp はコマンド入力中にUpでヒストリを遡る
+func (c *Cmdline) historyUp() {
+	if c.mode != modeCommand || len(c.history) == 0 {
+		return
+	}
+	if c.historyPos > 0 {
+		c.historyPos--
+	}
+	c.text = ":" + c.history[c.historyPos]
+}
+
+// historyDown はコマンド入力中にDownでヒストリを辿る
+func (c *Cmdline) historyDown() {
+	if c.mode != modeCommand || len(c.history) == 0 {
+		return
+	}
+	if c.historyPos < len(c.history)-1 {
+		c.historyPos++
+		c.text = ":" + c.history[c.historyPos]
+		return
+	}
+	c.historyPos = len(c.history)
+	c.text = ":"
+}
+
+// reverseISearch はCtrl-Rで、入力済みの文字列を含む直近のヒストリへジャンプする
+func (c *Cmdline) reverseISearch() {
+	if c.mode != modeCommand {
+		return
+	}
+	query := strings.TrimPrefix(c.text, ":")
+	for i := len(c.history) - 1; i >= 0; i-- {
+		if strings.Contains(c.history[i], query) {
+			c.text = ":" + c.history[i]
+			c.historyPos = i
+			return
+		}
+	}
+}
+
+// jumpToMatch は現在行から検索クエリの次のマッチへカーソルを移動する
+func (c *Cmdline) jumpToMatch(g *Gui) {
+	main := g.View("main")
+	c.searchFrom(g, main.cursory+main.drawingStartRow, 1)
+}
+
+// nextMatch はCtrl-Nで、現在行より後ろのマッチを含む行へ進み、その行の最初のマッチに
+// カーソルを置く。同じ行内の2つ目以降のマッチには移動しない
+func (c *Cmdline) nextMatch(g *Gui) {
+	main := g.View("main")
+	c.searchFrom(g, main.cursory+main.drawingStartRow+1, 1)
+}
+
+// prevMatch はCtrl-Pで、現在行より前のマッチを含む行へ戻り、その行の最初のマッチに
+// カーソルを置く。同じ行内の2つ目以降のマッチには移動しない
+func (c *Cmdline) prevMatch(g *Gui) {
+	main := g.View("main")
+	c.searchFrom(g, main.cursory+main.drawingStartRow-1, -1)
+}
+
+// searchFrom はstart行からstep方向に全行を走査し、最初に見つかったマッチへ飛ぶ
+func (c *Cmdline) searchFrom(g *Gui, start, step int) {
+	n := len(File.data)
+	if c.searchQuery == "" || n == 0 {
+		return
+	}
+
+	r := ((start % n) + n) % n
+	for i := 0; i < n; i++ {
+		if idx := strings.Index(File.data[r], c.searchQuery); idx != -1 {
+			col := utf8.RuneCountInString(File.data[r][:idx])
+			moveViewTo(g.View("main"), r, col)
+			return
+		}
+		r = ((r+step)%n + n) % n
+	}
+}
+
+// moveViewTo はViewのスクロール位置とカーソルを指定した行・列が見えるように合わせる
+func moveViewTo(v *View, row, col int) {
+	_, wsRow := v.Size()
+	v.drawingStartRow = max(0, row-wsRow/2)
+	v.cursory = row - v.drawingStartRow
+	v.drawingStartCol = 0
+	v.cursorx = col
+}
+
+// searchMatchRuns は検索クエリのマッチ箇所をハイライト用のStyleRunにする
+func searchMatchRuns(line string) []StyleRun {
+	if cmdline.searchQuery == "" {
+		return nil
+	}
+
+	var runs []StyleRun
+	i := 0
+	for {
+		idx := strings.Index(line[i:], cmdline.searchQuery)
+		if idx == -1 {
+			break
+		}
+		idx += i
+		runs = append(runs, StyleRun{
+			start: idx,
+			end:   idx + len(cmdline.searchQuery),
+			style: styleAttr{termbox.ColorBlack, termbox.ColorYellow},
+		})
+		i = idx + len(cmdline.searchQuery)
+	}
+	return runs
+}
+
+// handleInput は検索/コマンドプロンプトが開いている間の入力を処理する
+func (c *Cmdline) handleInput(g *Gui, p []byte) error {
+	if len(p) == 3 {
+		switch p[2] {
+		case ArrowUp:
+			c.historyUp()
+		case ArrowDown:
+			c.historyDown()
+		}
+		return nil
+	}
+
+	switch p[0] {
+	case Esc:
+		c.cancel(g)
+	case Enter:
+		c.accept(g)
+	case BackSpace:
+		c.backspace()
+	case Ctrln:
+		c.nextMatch(g)
+	case Ctrlp:
+		c.prevMatch(g)
+	case Ctrlr:
+		c.reverseISearch()
+	default:
+		c.insert(*(*string)(unsafe.Pointer(&p)))
+		if c.mode == modeSearch {
+			c.jumpToMatch(g)
+		}
+	}
+	return nil
+}
+
+// executeCommand は :w :q :wq :goto <n> :set tab=<n> を実行する
+func executeCommand(g *Gui, cmd string) {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return
+	}
+
+	switch fields[0] {
+	case "w":
+		toFile()
+	case "q":
+		g.running = false
+	case "wq":
+		toFile()
+		g.running = false
+	case "goto":
+		if len(fields) >= 2 {
+			if n, err := strconv.Atoi(fields[1]); err == nil {
+				row := n - 1
+				if maxRow := len(File.data) - 1; row > maxRow {
+					row = maxRow
+				}
+				if row < 0 {
+					row = 0
+				}
+				moveViewTo(g.View("main"), row, 0)
+			}
+		}
+	case "set":
+		if len(fields) >= 2 {
+			applySetting(fields[1])
+		}
+	}
+}
+
+// applySetting は "tab=<n>" のようなkey=value形式の設定を反映する
+func applySetting(kv string) {
+	parts := strings.SplitN(kv, "=", 2)
+	if len(parts) != 2 {
+		return
+	}
+	if parts[0] == "tab" {
+		if n, err := strconv.Atoi(parts[1]); err == nil && n > 0 {
+			tabWidth = n
+		}
+	}
+}
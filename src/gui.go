@@ -0,0 +1,171 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+
+	"github.com/nsf/termbox-go"
+	"github.com/pkg/term/termios"
+	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/sys/unix"
+)
+
+// Gui はターミナルのライフサイクル、View群、キーバインドを管理する
+type Gui struct {
+	views           map[string]*View
+	order           []string
+	keybindings     []*Keybinding
+	defaultTtystate unix.Termios
+	wsRow		   int
+	wsCol		   int
+	running		 bool
+}
+
+// NewGui はGuiを生成する
+func NewGui() *Gui {
+	return &Gui{views: make(map[string]*View)}
+}
+
+// Init はターミナルを非カノニカルモードにし、termboxを初期化する
+func (g *Gui) Init() error {
+	g.settingTermios()
+	if err := termbox.Init(); err != nil {
+		return err
+	}
+	g.getWindowSize()
+	enableMouseReporting()
+	return nil
+}
+
+// Close はtermboxとターミナル属性を元に戻す
+func (g *Gui) Close() {
+	disableMouseReporting()
+	termbox.Close()
+	g.resetRawMode()
+}
+
+// 起動時のtermiosの設定
+func (g *Gui) settingTermios() {
+	termios.Tcgetattr(uintptr(syscall.Stdin), &g.defaultTtystate)
+	ttystate := g.defaultTtystate
+	setRawMode(&ttystate)
+}
+
+// ターミナル属性をリセットする
+func (g *Gui) resetRawMode() {
+	termios.Tcsetattr(uintptr(syscall.Stdin), termios.TCSANOW, &g.defaultTtystate)
+}
+
+// ウィンドウサイズを取得し、Guiに設定する
+func (g *Gui) getWindowSize() {
+	var err error
+	g.wsCol, g.wsRow, err = terminal.GetSize(syscall.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// SetView は名前に対応するViewを取得し、無ければ生成する
+func (g *Gui) SetView(name string, x0, y0, x1, y1 int) *View {
+	v, ok := g.views[name]
+	if !ok {
+		v = newView(name, x0, y0, x1, y1)
+		g.views[name] = v
+		g.order = append(g.order, name)
+		return v
+	}
+	v.x0, v.y0, v.x1, v.y1 = x0, y0, x1, y1
+	return v
+}
+
+// View は登録済みのViewを名前で取得する
+func (g *Gui) View(name string) *View {
+	return g.views[name]
+}
+
+// Layout はウィンドウサイズをもとに各Viewの矩形を計算し直す
+func (g *Gui) Layout() {
+	g.SetView("main", 0, 0, g.wsCol-1, g.wsRow-3)
+	g.SetView("status", 0, g.wsRow-2, g.wsCol-1, g.wsRow-2)
+	g.SetView("cmdline", 0, g.wsRow-1, g.wsCol-1, g.wsRow-1)
+}
+
+// draw は登録順に全Viewを描画する
+func (g *Gui) draw() {
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	for _, name := range g.order {
+		g.views[name].Draw()
+	}
+	main := g.View("main")
+	termbox.SetCursor(main.x0+main.screenCursorX(), main.y0+main.cursory)
+	termbox.Flush()
+}
+
+// バッファの値を取得する
+func readBuffer(bufCh chan []byte) {
+	buf := make([]byte, 1024)
+	for {
+		if n, err := syscall.Read(syscall.Stdin, buf); err == nil {
+			p := make([]byte, n)
+			copy(p, buf[:n])
+			bufCh <- p
+		}
+	}
+}
+
+// dispatch は読み取った入力バイト列をキーイベントに変換する
+// 検索/コマンドプロンプトが開いている間はミニバッファが入力を横取りする
+func (g *Gui) dispatch(p []byte) error {
+	if cmdline.mode != modeNormal {
+		return cmdline.handleInput(g, p)
+	}
+
+	if ev, ok := parseMouseEvent(p); ok {
+		return g.handleMouse(ev)
+	}
+
+	switch len(p) {
+	case 3:
+		switch p[2] {
+		case ArrowUp, ArrowDown, ArrowRight, ArrowLeft:
+			return g.onKey("main", Key(p[2]), ModNone)
+		}
+	default:
+		switch p[0] {
+		case Enter, BackSpace, Ctrlq, Ctrls, Ctrlz, Ctrly, Ctrlr, Ctrlc, Ctrlx, Ctrlv, Ctrlk, Ctrlj, Ctrll, Ctrlh, Tab, Ctrlf, Ctrlcolon:
+			return g.onKey("main", Key(p[0]), ModNone)
+		default:
+			g.View("main").textInsertion(*(*string)(unsafe.Pointer(&p)))
+			g.View("main").moveCursor(1, 0)
+		}
+	}
+	return nil
+}
+
+// MainLoop は入力とSIGWINCHを待ち受け、キーバインド実行後に再描画する
+func (g *Gui) MainLoop() error {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGWINCH)
+
+	bufCh := make(chan []byte, 1)
+	go readBuffer(bufCh)
+
+	g.running = true
+	g.draw()
+	for g.running {
+		select {
+		case <-sig:
+			g.getWindowSize()
+			g.Layout()
+		case p := <-bufCh:
+			if err := g.dispatch(p); err != nil {
+				return err
+			}
+		}
+		g.draw()
+	}
+	return nil
+}
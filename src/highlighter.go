@@ -0,0 +1,205 @@
+package main
+
+import (
+	"embed"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+	"gopkg.in/yaml.v2"
+)
+
+// embeddedSyntaxRules は~/.config/texteditor/syntaxが無い/空の環境向けに
+// 同梱するGo/Python/Markdownのビルトインルールファイル
+//
+//go:embed syntax/*.yaml
+var embeddedSyntaxRules embed.FS
+
+// styleAttr は前景色と背景色の組
+type styleAttr struct {
+	fg termbox.Attribute
+	bg termbox.Attribute
+}
+
+// StyleRun は1行のうち [start,end) のバイト範囲にstyleを適用する指示
+type StyleRun struct {
+	start int
+	end   int
+	style styleAttr
+}
+
+// Highlighter はファイルの中身から行ごとの色付け情報を取り出す
+type Highlighter interface {
+	Highlight(line string) []StyleRun
+}
+
+// syntaxRuleFile は ~/.config/texteditor/syntax/*.yaml 1ファイル分の定義
+type syntaxRuleFile struct {
+	Extensions []string		  `yaml:"extensions"`
+	Patterns   []patternRuleFile `yaml:"patterns"`
+	Groups	 map[string]string  `yaml:"groups"`
+}
+
+type patternRuleFile struct {
+	Regex string `yaml:"regex"`
+	Group string `yaml:"group"`
+}
+
+// compiledPattern はロード時に正規表現と色をコンパイル/解決済みにしたパターン
+type compiledPattern struct {
+	re	*regexp.Regexp
+	style styleAttr
+}
+
+// compiledRule は1言語分のルールファイルをコンパイルしたもの
+type compiledRule struct {
+	extensions []string
+	patterns   []compiledPattern
+}
+
+// colorByName はルールファイルで指定する色名をtermboxの色に変換する
+func colorByName(name string) termbox.Attribute {
+	switch name {
+	case "blue":
+		return termbox.ColorBlue
+	case "magenta":
+		return termbox.ColorLightMagenta
+	case "green":
+		return termbox.ColorLightGreen
+	case "cyan":
+		return termbox.ColorCyan
+	case "red":
+		return termbox.ColorRed
+	case "yellow":
+		return termbox.ColorYellow
+	case "white":
+		return termbox.ColorWhite
+	}
+	return termbox.ColorDefault
+}
+
+// syntaxDir はルールファイルを探すディレクトリ (~/.config/texteditor/syntax)
+func syntaxDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".config", "texteditor", "syntax")
+	}
+	return filepath.Join(home, ".config", "texteditor", "syntax")
+}
+
+// compileRule はYAMLから読んだルールを正規表現コンパイル済みの形に変換する
+func compileRule(raw *syntaxRuleFile) (*compiledRule, error) {
+	cr := &compiledRule{extensions: raw.Extensions}
+	for _, p := range raw.Patterns {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, err
+		}
+		fg := colorByName(raw.Groups[p.Group])
+		cr.patterns = append(cr.patterns, compiledPattern{re: re, style: styleAttr{fg, termbox.ColorDefault}})
+	}
+	return cr, nil
+}
+
+// loadSyntaxRules はsyntaxDir()以下の*.yamlを全て読み込みコンパイルする
+// ユーザー設定が存在しない/空の場合は同梱のビルトインルールにフォールバックする
+func loadSyntaxRules() []*compiledRule {
+	rules := loadSyntaxRulesFrom(os.DirFS(syntaxDir()), ".")
+	if len(rules) == 0 {
+		rules = loadSyntaxRulesFrom(embeddedSyntaxRules, "syntax")
+	}
+	return rules
+}
+
+// loadSyntaxRulesFrom はfsys内のdir以下の*.yamlを読み込みコンパイルする
+func loadSyntaxRulesFrom(fsys fs.FS, dir string) []*compiledRule {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil
+	}
+
+	var rules []*compiledRule
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".yaml") {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var raw syntaxRuleFile
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			continue
+		}
+		cr, err := compileRule(&raw)
+		if err != nil {
+			continue
+		}
+		rules = append(rules, cr)
+	}
+	return rules
+}
+
+// ruleForPath はファイルの拡張子に合うルールを探す
+func ruleForPath(rules []*compiledRule, path string) *compiledRule {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	for _, r := range rules {
+		for _, e := range r.extensions {
+			if e == ext {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// RuleHighlighter はルールファイル1つ分のパターンを行ごとに適用するHighlighter
+// 同じ内容の行を繰り返し再走査しないよう、行内容のハッシュでマッチ結果をキャッシュする
+type RuleHighlighter struct {
+	rule  *compiledRule
+	cache map[uint64][]StyleRun
+}
+
+// NewHighlighter はファイルパスの拡張子に合うルールを読み込んだHighlighterを作る
+// 合うルールが無ければ色付けを行わないHighlighterになる
+func NewHighlighter(path string) *RuleHighlighter {
+	rules := loadSyntaxRules()
+	return &RuleHighlighter{rule: ruleForPath(rules, path), cache: make(map[uint64][]StyleRun)}
+}
+
+func hashLine(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// Highlight は1行分のStyleRunを返す。同じ行内容であればキャッシュを返す
+func (h *RuleHighlighter) Highlight(line string) []StyleRun {
+	if h.rule == nil {
+		return nil
+	}
+
+	key := hashLine(line)
+	if runs, ok := h.cache[key]; ok {
+		return runs
+	}
+
+	// File.dataの行は末尾に"\n"を含むため、RE2の$/.が行末として
+	// 扱えるよう取り除いてからマッチさせる
+	matchLine := strings.TrimSuffix(line, "\n")
+
+	var runs []StyleRun
+	for _, p := range h.rule.patterns {
+		for _, m := range p.re.FindAllStringIndex(matchLine, -1) {
+			runs = append(runs, StyleRun{start: m[0], end: m[1], style: p.style})
+		}
+	}
+	h.cache[key] = runs
+	return runs
+}
+
+var highlighter *RuleHighlighter
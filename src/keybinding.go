@@ -0,0 +1,151 @@
+package main
+
+// Key は入力された一文字分のキーコードを表す
+type Key byte
+
+// Modifier はCtrl等の修飾キーを表す
+type Modifier int
+
+const (
+	ModNone Modifier = iota
+)
+
+// Keybinding は (view, key, mod) の組にハンドラを対応付ける
+type Keybinding struct {
+	viewName string // 空文字列のときは全Viewで有効
+	key      Key
+	mod      Modifier
+	handler  func(*Gui, *View) error
+}
+
+// matches はイベントがこのキーバインドに合致するか判定する
+func (kb *Keybinding) matches(viewName string, key Key, mod Modifier) bool {
+	if kb.viewName != "" && kb.viewName != viewName {
+		return false
+	}
+	return kb.key == key && kb.mod == mod
+}
+
+// SetKeybinding は (viewName, key, mod) にハンドラを登録する
+// viewName を空文字列にするとどのViewがアクティブでも発火する
+func (g *Gui) SetKeybinding(viewName string, key Key, mod Modifier, handler func(*Gui, *View) error) {
+	g.keybindings = append(g.keybindings, &Keybinding{
+		viewName: viewName,
+		key:      key,
+		mod:      mod,
+		handler:  handler,
+	})
+}
+
+// onKey は登録済みキーバインドの中から合致するものを実行する
+// 合致するハンドラが見つからなければ何もしない
+func (g *Gui) onKey(viewName string, key Key, mod Modifier) error {
+	for _, kb := range g.keybindings {
+		if kb.matches(viewName, key, mod) {
+			v := g.View(viewName)
+			return kb.handler(g, v)
+		}
+	}
+	return nil
+}
+
+// defaultKeybindings はメインビューの標準のキー操作を登録する
+func defaultKeybindings(g *Gui) {
+	g.SetKeybinding("main", Key(ArrowUp), ModNone, func(g *Gui, v *View) error {
+		v.moveCursor(0, -1)
+		return nil
+	})
+	g.SetKeybinding("main", Key(ArrowDown), ModNone, func(g *Gui, v *View) error {
+		v.moveCursor(0, 1)
+		return nil
+	})
+	g.SetKeybinding("main", Key(ArrowRight), ModNone, func(g *Gui, v *View) error {
+		v.moveCursor(1, 0)
+		return nil
+	})
+	g.SetKeybinding("main", Key(ArrowLeft), ModNone, func(g *Gui, v *View) error {
+		v.moveCursor(-1, 0)
+		return nil
+	})
+	g.SetKeybinding("main", Key(Enter), ModNone, func(g *Gui, v *View) error {
+		v.enter()
+		v.moveCursor(0, 1)
+		return nil
+	})
+	g.SetKeybinding("main", Key(BackSpace), ModNone, func(g *Gui, v *View) error {
+		v.backSpace()
+		return nil
+	})
+	g.SetKeybinding("main", Key(Ctrlq), ModNone, func(g *Gui, v *View) error {
+		g.running = false
+		return nil
+	})
+	g.SetKeybinding("main", Key(Ctrls), ModNone, func(g *Gui, v *View) error {
+		toFile()
+		return nil
+	})
+	g.SetKeybinding("main", Key(Ctrlz), ModNone, func(g *Gui, v *View) error {
+		if File.buf.Undo() {
+			File.sync()
+			v.checkX(v.cursory + v.drawingStartRow)
+		}
+		return nil
+	})
+	g.SetKeybinding("main", Key(Ctrly), ModNone, func(g *Gui, v *View) error {
+		if File.buf.Redo() {
+			File.sync()
+			v.checkX(v.cursory + v.drawingStartRow)
+		}
+		return nil
+	})
+	g.SetKeybinding("main", Key(Ctrlr), ModNone, func(g *Gui, v *View) error {
+		v.deleteRow()
+		return nil
+	})
+	g.SetKeybinding("main", Key(Ctrlk), ModNone, func(g *Gui, v *View) error {
+		v.moveCursor(0, -1)
+		return nil
+	})
+	g.SetKeybinding("main", Key(Ctrlj), ModNone, func(g *Gui, v *View) error {
+		v.moveCursor(0, 1)
+		return nil
+	})
+	g.SetKeybinding("main", Key(Ctrll), ModNone, func(g *Gui, v *View) error {
+		v.moveCursor(1, 0)
+		return nil
+	})
+	g.SetKeybinding("main", Key(Ctrlh), ModNone, func(g *Gui, v *View) error {
+		v.moveCursor(-1, 0)
+		return nil
+	})
+	g.SetKeybinding("main", Key(Tab), ModNone, func(g *Gui, v *View) error {
+		v.textInsertion("	")
+		v.moveCursor(tabWidth, 0)
+		return nil
+	})
+	g.SetKeybinding("main", Key(Ctrlc), ModNone, func(g *Gui, v *View) error {
+		if text, ok := v.selectedText(); ok {
+			writeClipboard(text)
+		}
+		return nil
+	})
+	g.SetKeybinding("main", Key(Ctrlx), ModNone, func(g *Gui, v *View) error {
+		if text, ok := v.selectedText(); ok {
+			writeClipboard(text)
+			v.deleteSelection()
+		}
+		return nil
+	})
+	g.SetKeybinding("main", Key(Ctrlv), ModNone, func(g *Gui, v *View) error {
+		v.pasteText(readClipboard())
+		return nil
+	})
+	g.SetKeybinding("main", Key(Ctrlf), ModNone, func(g *Gui, v *View) error {
+		cmdline.startSearch(g)
+		return nil
+	})
+	g.SetKeybinding("main", Key(Ctrlcolon), ModNone, func(g *Gui, v *View) error {
+		cmdline.startCommand(g)
+		return nil
+	})
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// mouseButton はSGRマウスレポートのボタン番号を表す
+type mouseButton int
+
+const (
+	mouseLeft	  mouseButton = 0
+	mouseMiddle	mouseButton = 1
+	mouseRight	 mouseButton = 2
+	mouseWheelUp   mouseButton = 64
+	mouseWheelDown mouseButton = 65
+)
+
+// mouseEvent は1回分のマウスレポートを表す。x, yは0始まりの画面座標
+type mouseEvent struct {
+	button  mouseButton
+	x, y	int
+	pressed bool // false は release (SGRの'm')
+	drag	bool
+}
+
+// enableMouseReporting はSGR拡張マウスレポート (ドラッグ込み) を端末に要求する
+func enableMouseReporting() {
+	fmt.Fprint(os.Stdout, "\x1b[?1002h\x1b[?1006h")
+}
+
+// disableMouseReporting は終了時にマウスレポートを無効化する
+func disableMouseReporting() {
+	fmt.Fprint(os.Stdout, "\x1b[?1006l\x1b[?1002l")
+}
+
+// parseMouseEvent はSGR拡張マウスレポート "\x1b[<Cb;Cx;Cy(M|m)" を解釈する
+func parseMouseEvent(p []byte) (mouseEvent, bool) {
+	if len(p) < 6 || p[0] != Esc || p[1] != '[' || p[2] != '<' {
+		return mouseEvent{}, false
+	}
+
+	body := string(p[3:])
+	end := strings.IndexAny(body, "Mm")
+	if end == -1 {
+		return mouseEvent{}, false
+	}
+
+	fields := strings.Split(body[:end], ";")
+	if len(fields) != 3 {
+		return mouseEvent{}, false
+	}
+	cb, err1 := strconv.Atoi(fields[0])
+	cx, err2 := strconv.Atoi(fields[1])
+	cy, err3 := strconv.Atoi(fields[2])
+	if err1 != nil || err2 != nil || err3 != nil {
+		return mouseEvent{}, false
+	}
+
+	return mouseEvent{
+		button:  mouseButton(cb &^ 32),
+		x:	   cx - 1,
+		y:	   cy - 1,
+		pressed: body[end] == 'M',
+		drag:	cb&32 != 0,
+	}, true
+}
+
+// clickState はダブル/トリプルクリック判定用の直前クリックの記録
+type clickState struct {
+	at	time.Time
+	row   int
+	col   int
+	count int
+}
+
+var lastClick clickState
+
+// multiClickWindow はこの時間内に同じセルをクリックすると連続クリックとみなす
+const multiClickWindow = 400 * time.Millisecond
+
+// handleMouse はマウスイベントをメインビューに適用する
+func (g *Gui) handleMouse(ev mouseEvent) error {
+	main := g.View("main")
+	if ev.y < main.y0 || ev.y > main.y1 || ev.x < main.x0 || ev.x > main.x1 {
+		return nil
+	}
+
+	switch {
+	case ev.button == mouseWheelUp && ev.pressed:
+		main.drawingStartRow = max(0, main.drawingStartRow-3)
+		return nil
+	case ev.button == mouseWheelDown && ev.pressed:
+		if len(File.data)-1-main.drawingStartRow > 3 {
+			main.drawingStartRow += 3
+		}
+		return nil
+	}
+
+	if ev.button != mouseLeft || len(File.data) == 0 {
+		return nil
+	}
+
+	row, col := main.cellAt(ev.x-main.x0, ev.y-main.y0)
+
+	if ev.drag {
+		if !main.selection.active {
+			return nil
+		}
+		main.selection.head = selectionPoint{row, col}
+		main.cursory, main.cursorx = ev.y-main.y0, col-main.drawingStartCol
+		return nil
+	}
+
+	if !ev.pressed {
+		return nil
+	}
+
+	main.selection = Selection{anchor: selectionPoint{row, col}, head: selectionPoint{row, col}, active: true}
+	main.cursory = ev.y - main.y0
+	main.cursorx = col - main.drawingStartCol
+
+	switch g.registerClick(row, col) {
+	case 2:
+		main.selectWord(row, col)
+	case 3:
+		main.selectLine(row)
+	}
+	return nil
+}
+
+// registerClick は直前のクリックと同じ位置へmultiClickWindow以内に
+// クリックされた場合、連続クリック数を積み増して返す
+func (g *Gui) registerClick(row, col int) int {
+	now := time.Now()
+	if lastClick.row == row && lastClick.col == col && now.Sub(lastClick.at) < multiClickWindow {
+		lastClick.count++
+	} else {
+		lastClick.count = 1
+	}
+	lastClick.at, lastClick.row, lastClick.col = now, row, col
+	return lastClick.count
+}
+
+// cellAt はView内の画面座標(x, y)を、ファイル中の(行, ルーン列)に変換する
+func (v *View) cellAt(x, y int) (int, int) {
+	row := y + v.drawingStartRow
+	if row < 0 {
+		row = 0
+	}
+	if row >= len(File.data) {
+		row = max(0, len(File.data)-1)
+	}
+
+	runes := []rune(File.data[row])
+	col := v.drawingStartCol
+	width := 0
+	for col < len(runes) {
+		w := runewidth.RuneWidth(runes[col])
+		if width+w > x {
+			break
+		}
+		width += w
+		col++
+	}
+	return row, col
+}
@@ -0,0 +1,122 @@
+package main
+
+import "strings"
+
+// selectionPoint は選択範囲の一端を行・ルーン列で表す
+type selectionPoint struct {
+	row int
+	col int
+}
+
+// Selection はマウスドラッグ等で張られた選択範囲。
+// anchorがドラッグ開始点、headが現在のドラッグ先を表す
+type Selection struct {
+	anchor selectionPoint
+	head   selectionPoint
+	active bool
+}
+
+// normalized はanchor/headを文書中の出現順に並べ替えて返す
+func (s Selection) normalized() (selectionPoint, selectionPoint) {
+	a, h := s.anchor, s.head
+	if a.row > h.row || (a.row == h.row && a.col > h.col) {
+		a, h = h, a
+	}
+	return a, h
+}
+
+// inSelection はView内の(row, ルーン列col)が選択範囲に含まれるか判定する
+func (v *View) inSelection(row, col int) bool {
+	if !v.selection.active {
+		return false
+	}
+	a, h := v.selection.normalized()
+	if row < a.row || row > h.row {
+		return false
+	}
+	if row == a.row && col < a.col {
+		return false
+	}
+	if row == h.row && col >= h.col {
+		return false
+	}
+	return true
+}
+
+// selectWord はクリックした位置の単語全体 ([a-zA-Z0-9_]の連続) を選択する
+func (v *View) selectWord(row, col int) {
+	runes := []rune(File.data[row])
+	start, end := col, col
+	for start > 0 && isWordRune(runes[start-1]) {
+		start--
+	}
+	for end < len(runes) && isWordRune(runes[end]) {
+		end++
+	}
+	v.selection = Selection{anchor: selectionPoint{row, start}, head: selectionPoint{row, end}, active: true}
+}
+
+// selectLine は行全体を選択する
+func (v *View) selectLine(row int) {
+	runeLen := len([]rune(File.data[row]))
+	v.selection = Selection{anchor: selectionPoint{row, 0}, head: selectionPoint{row, runeLen}, active: true}
+}
+
+// isWordRune は単語の一部とみなす文字かどうかを返す
+func isWordRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// selectedText は選択範囲の文字列を返す。選択が無ければokはfalse
+func (v *View) selectedText() (string, bool) {
+	if !v.selection.active {
+		return "", false
+	}
+	a, h := v.selection.normalized()
+	start, end := File.offsetOf(a.row, a.col), File.offsetOf(h.row, h.col)
+	if end <= start {
+		return "", false
+	}
+	return File.buf.Snapshot()[start:end], true
+}
+
+// deleteSelection は選択範囲があればそれを削除し、カーソルを選択先頭に置く。
+// 削除を行った場合はtrueを返す
+func (v *View) deleteSelection() bool {
+	if !v.selection.active {
+		return false
+	}
+	a, h := v.selection.normalized()
+	v.selection = Selection{}
+
+	start, end := File.offsetOf(a.row, a.col), File.offsetOf(h.row, h.col)
+	if end <= start {
+		return false
+	}
+
+	File.buf.Delete(start, end-start)
+	File.sync()
+
+	v.cursory = a.row - v.drawingStartRow
+	v.cursorx = a.col - v.drawingStartCol
+	v.checkX(a.row)
+	return true
+}
+
+// pasteText は選択範囲を置き換える形でtextを挿入し、カーソルを末尾に進める
+func (v *View) pasteText(text string) {
+	if text == "" {
+		return
+	}
+	v.deleteSelection()
+	v.textInsertion(text)
+
+	lines := strings.Split(text, "\n")
+	if len(lines) == 1 {
+		v.moveCursor(len([]rune(text)), 0)
+		return
+	}
+	v.cursory += len(lines) - 1
+	v.cursorx = len([]rune(lines[len(lines)-1]))
+	v.checkX(v.cursory + v.drawingStartRow)
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"github.com/mattn/go-runewidth"
+	"github.com/nsf/termbox-go"
+)
+
+// View はレイアウト上の矩形と、その中身を描画するための状態を持つ
+// cursorx/drawingStartCol はルーン単位の列インデックスで管理する
+type View struct {
+	name				string
+	x0, y0, x1, y1	  int // レイアウト上の矩形 (終端含む)
+	cursorx			 int
+	cursory			 int
+	drawingStartRow	 int
+	drawingStartCol	 int
+	selection		   Selection // マウスドラッグ等で張られた選択範囲
+}
+
+// newView は指定した矩形を持つViewを生成する
+func newView(name string, x0, y0, x1, y1 int) *View {
+	return &View{name: name, x0: x0, y0: y0, x1: x1, y1: y1}
+}
+
+// Size はViewの表示可能な列数・行数を返す
+func (v *View) Size() (int, int) {
+	return v.x1 - v.x0 + 1, v.y1 - v.y0 + 1
+}
+
+// Draw はViewの種類に応じて中身を描画する
+func (v *View) Draw() {
+	switch v.name {
+	case "main":
+		v.drawMain()
+	case "status":
+		v.drawStatus()
+	case "cmdline":
+		v.drawCmdline()
+	}
+}
+
+// drawMain はファイルの中身をシンタックスハイライトとともに描画する
+// 全角文字は2セル、結合文字は0セルとして進め、全角文字の後続セルは上書きしない
+func (v *View) drawMain() {
+	wsCol, wsRow := v.Size()
+
+	for y := 0; y < wsRow; y++ {
+		// もしファイルの行数が表示限界の行数よりも
+		// 小さい時に"~"を表示する
+		if y+v.drawingStartRow >= len(File.data) {
+			if y == 0 {
+				continue
+			}
+			termbox.SetCell(v.x0, v.y0+y, rune('~'), termbox.ColorDefault, termbox.ColorDefault)
+			continue
+		}
+
+		line := File.data[y+v.drawingStartRow]
+		runeText := []rune(line)
+
+		runs := highlighter.Highlight(line)
+		if cmdline.mode == modeSearch {
+			runs = append(runs, searchMatchRuns(line)...)
+		}
+		attrs := styleRunsToRuneAttrs(line, runs, len(runeText))
+
+		row := y + v.drawingStartRow
+		x := 0
+		for j := v.drawingStartCol; j < min(v.drawingStartCol+wsCol, len(runeText)); j++ {
+			w := runewidth.RuneWidth(runeText[j])
+			if runeText[j] == '\t' {
+				// RuneWidthは'\t'の幅を0として返すため、カーソル移動幅
+				// (tabWidth)に合わせて別扱いする
+				w = tabWidth
+			}
+			if x+w > wsCol {
+				break
+			}
+			style := attrs[j]
+			if v.inSelection(row, j) {
+				style.fg, style.bg = style.bg, style.fg
+				if style.fg == termbox.ColorDefault && style.bg == termbox.ColorDefault {
+					style = styleAttr{termbox.ColorBlack, termbox.ColorWhite}
+				}
+			}
+			termbox.SetCell(v.x0+x, v.y0+y, runeText[j], style.fg, style.bg)
+			x += w
+		}
+	}
+}
+
+// styleRunsToRuneAttrs はバイトオフセットで表現されたStyleRun列を、
+// ルーン単位のstyleAttr配列に変換する。後のRunほど前のRunを上書きする
+func styleRunsToRuneAttrs(line string, runs []StyleRun, runeLen int) []styleAttr {
+	attrs := make([]styleAttr, runeLen)
+	for i := range attrs {
+		attrs[i] = styleAttr{termbox.ColorDefault, termbox.ColorDefault}
+	}
+	if len(runs) == 0 {
+		return attrs
+	}
+
+	byteToRune := make([]int, len(line)+1)
+	ri, bi := 0, 0
+	for _, r := range line {
+		sz := len(string(r))
+		for k := 0; k < sz; k++ {
+			byteToRune[bi+k] = ri
+		}
+		bi += sz
+		ri++
+	}
+	byteToRune[len(line)] = ri
+
+	for _, run := range runs {
+		start, end := byteToRune[run.start], byteToRune[run.end]
+		for j := start; j < end && j < runeLen; j++ {
+			attrs[j] = run.style
+		}
+	}
+	return attrs
+}
+
+// drawStatus はファイルパスとカーソル位置を表示する
+func (v *View) drawStatus() {
+	main := gui.View("main")
+	text := []rune(File.path + " - " + itoa(main.cursory+main.drawingStartRow+1) + "," + itoa(main.cursorx+main.drawingStartCol+1))
+	wsCol, _ := v.Size()
+	for x := 0; x < min(len(text), wsCol); x++ {
+		termbox.SetCell(v.x0+x, v.y0, text[x], termbox.ColorBlack, termbox.ColorWhite)
+	}
+}
+
+// drawCmdline はコマンドラインの入力内容を表示する
+func (v *View) drawCmdline() {
+	text := []rune(cmdline.text)
+	wsCol, _ := v.Size()
+	for x := 0; x < min(len(text), wsCol); x++ {
+		termbox.SetCell(v.x0+x, v.y0, text[x], termbox.ColorDefault, termbox.ColorDefault)
+	}
+}
+
+// itoa は外部パッケージなしで非負整数を文字列化する
+func itoa(n int) string {
+	if n == 0 {
+		return "0"
+	}
+	digits := ""
+	for n > 0 {
+		digits = string(rune('0'+n%10)) + digits
+		n /= 10
+	}
+	return digits
+}
+
+// screenCursorX はカーソル位置(ルーン列)を、全角・結合文字の幅を加味した
+// 実際の画面上の列に変換する
+func (v *View) screenCursorX() int {
+	r := v.cursory + v.drawingStartRow
+	if r < 0 || r >= len(File.data) {
+		return 0
+	}
+	runes := []rune(File.data[r])
+	end := min(v.drawingStartCol+v.cursorx, len(runes))
+
+	x := 0
+	for i := v.drawingStartCol; i < end; i++ {
+		x += runewidth.RuneWidth(runes[i])
+	}
+	return x
+}
+
+// visibleRuneCount は現在行のdrawingStartColから、画面幅wsColに収まる
+// ルーン数を返す (全角文字は2セル分として数える)
+func (v *View) visibleRuneCount(wsCol int) int {
+	r := v.cursory + v.drawingStartRow
+	if r < 0 || r >= len(File.data) {
+		return wsCol
+	}
+	runes := []rune(File.data[r])
+
+	width, n := 0, 0
+	for i := v.drawingStartCol; i < len(runes); i++ {
+		w := runewidth.RuneWidth(runes[i])
+		if width+w > wsCol {
+			break
+		}
+		width += w
+		n++
+	}
+	return n
+}
+
+// カーソル移動制御
+func (v *View) moveCursor(addx int, addy int) {
+	v.canMoveCursor(addx, addy)
+	termbox.SetCursor(v.x0+v.screenCursorX(), v.y0+v.cursory)
+	termbox.Flush()
+}
+
+// カーソル位置を移動する
+func (v *View) canMoveCursor(addx int, addy int) {
+	wsCol, wsRow := v.Size()
+	visible := v.visibleRuneCount(wsCol)
+
+	X := v.cursorx + addx
+	if X >= 0 && X < visible {
+		v.cursorx = X
+	} else {
+		v.controlHorizontalMovement(X, visible)
+	}
+
+	Y := v.cursory + addy
+	if Y >= 0 && Y < wsRow && len(File.data) > Y {
+		v.cursory = Y
+	} else {
+		v.controlVerticalMovement(Y)
+	}
+
+	v.checkX(v.cursory + v.drawingStartRow)
+}
+
+// 垂直移動したときに、現在の描画位置よりも
+// 文字列が短かった場合に文字列の最後尾にカーソルを移動する
+func (v *View) checkX(r int) {
+	wsCol, _ := v.Size()
+
+	if len(File.data) == 0 {
+		v.cursorx = 0
+		v.drawingStartCol = 0
+		return
+	}
+
+	// rはUndo/Redo等で行数が変わる前のカーソル位置を指していることがあるため、
+	// 現在のFile.dataに収まるよう詰め直してからvの表示位置も合わせる
+	if last := len(File.data) - 1; r > last {
+		r = last
+	}
+	if r < 0 {
+		r = 0
+	}
+	v.drawingStartRow = min(v.drawingStartRow, r)
+	v.cursory = r - v.drawingStartRow
+
+	runeLen := len([]rune(File.data[r]))
+	length := runeLen - v.drawingStartCol
+	if length <= 0 {
+		v.cursorx = 0
+		v.drawingStartCol = max(0, runeLen-1)
+	}
+
+	visible := v.visibleRuneCount(wsCol)
+	if length > 0 && length < visible && v.cursorx > length-1 {
+		v.cursorx = length - 1
+	}
+}
+
+// 水平移動を管理 (ルーン単位)
+func (v *View) controlHorizontalMovement(X, visible int) {
+	// 左スクロール
+	if X < 0 && v.drawingStartCol > 0 {
+		v.cursorx = 0
+		v.drawingStartCol--
+	}
+	// 右スクロール
+	runeLen := len([]rune(File.data[v.cursory+v.drawingStartRow]))
+	if X >= visible && runeLen-1-v.drawingStartCol >= visible {
+		v.cursorx = visible
+		v.drawingStartCol++
+	}
+}
+
+// 垂直移動を管理
+func (v *View) controlVerticalMovement(Y int) {
+	_, wsRow := v.Size()
+
+	// 上スクロール
+	if Y < 0 && v.drawingStartRow > 0 {
+		v.cursory = 0
+		v.drawingStartRow--
+	}
+	// 下スクロール
+	if Y >= wsRow && len(File.data)-1-v.drawingStartRow >= wsRow {
+		v.cursory = wsRow - 1
+		v.drawingStartRow++
+	}
+}
+
+// 文字を挿入する
+func (v *View) textInsertion(s string) {
+	v.deleteSelection()
+
+	r := v.cursory + v.drawingStartRow
+	c := v.cursorx + v.drawingStartCol
+
+	if len(File.data) == 0 {
+		File.buf.Insert(0, s+"\n")
+		File.sync()
+		return
+	}
+
+	File.buf.Insert(File.offsetOf(r, c), s)
+	File.sync()
+}
+
+// enterを押したとき
+func (v *View) enter() {
+	r := v.cursory + v.drawingStartRow
+	c := v.cursorx + v.drawingStartCol
+
+	if len(File.data) == 0 {
+		File.buf.Insert(0, "\n")
+		File.sync()
+		return
+	}
+
+	runes := []rune(File.data[r])
+	indent := isTab(string(runes[:c]))
+	File.buf.Insert(File.offsetOf(r, c), "\n"+indent)
+	File.sync()
+}
+
+// BackSpace
+func (v *View) backSpace() {
+	if v.deleteSelection() {
+		return
+	}
+
+	r := v.cursory + v.drawingStartRow
+	c := v.cursorx + v.drawingStartCol
+
+	if c == 0 && r == 0 {
+		return
+	}
+
+	if c == 0 && r > 0 {
+		prevRunes := []rune(File.data[r-1])
+		length := len(prevRunes)
+		pos := File.offsetOf(r-1, length-1)
+		File.buf.Delete(pos, len(string(prevRunes[length-1])))
+		File.sync()
+		v.moveCursor(length-1, -1)
+		return
+	}
+
+	runes := []rune(File.data[r])
+	pos := File.offsetOf(r, c-1)
+	File.buf.Delete(pos, len(string(runes[c-1])))
+	File.sync()
+	v.moveCursor(-1, 0)
+}
+
+// 一行削除する
+func (v *View) deleteRow() {
+	if len(File.data) == 0 {
+		return
+	}
+
+	r := v.cursory + v.drawingStartRow
+	File.buf.Delete(File.offsetOf(r, 0), len(File.data[r]))
+	File.sync()
+	v.moveCursor(0, -1)
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+// Undo/RedoでFile.dataの行数が縮んだ後にcheckXへ古いカーソル行を渡しても
+// パニックせず、カーソルが有効な行へ詰め直されることを確認する
+func TestCheckXClampsToShrunkBuffer(t *testing.T) {
+	File = TString{buf: newBuffer([]string{"a\n", "b\n", "c\n"})}
+	File.sync()
+
+	v := newView("main", 0, 0, 9, 9)
+	v.drawingStartRow = 2
+	v.cursory = 0 // points at row 2, the last line
+
+	File.data = File.data[:1] // 外部からUndo等で行数が縮んだ状況を再現する
+
+	v.checkX(2)
+
+	if r := v.cursory + v.drawingStartRow; r >= len(File.data) {
+		t.Fatalf("cursor row %d is still out of range for %d lines", r, len(File.data))
+	}
+}
+
+// 空のバッファに対するdeleteRowはパニックしない
+func TestDeleteRowOnEmptyBuffer(t *testing.T) {
+	File = TString{buf: newBuffer([]string{})}
+	File.sync()
+
+	v := newView("main", 0, 0, 9, 9)
+	v.deleteRow()
+}